@@ -0,0 +1,55 @@
+/**
+ * SOCKS5 / transparent TCP gateway that bridges TCP apps into VARA RF sessions.
+ *
+ * Program must be invoked with the -c flag to set myCall.
+ * Setting VARA_DEBUG environment variable to anything will cause additional logging output.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/n8jja/Pat-Vara/vara"
+	"github.com/n8jja/Pat-Vara/vara/socksgw"
+)
+
+func main() {
+	var (
+		myCall      = flag.String("c", "", "the callsign of my station")
+		listen      = flag.String("listen", ":1080", "address to listen for TCP connections")
+		scheme      = flag.String("scheme", "varahf", "VARA scheme to dial with")
+		user        = flag.String("user", "", "SOCKS5 username (with -pass, enables USERNAME/PASSWORD auth)")
+		pass        = flag.String("pass", "", "SOCKS5 password (with -user, enables USERNAME/PASSWORD auth)")
+		transparent = flag.Bool("transparent", false, "hardwire -listen to -remote instead of serving SOCKS5")
+		remote      = flag.String("remote", "", "remote callsign to dial in transparent mode, e.g. W1AW-10?bw=500")
+		dialTimeout = flag.Duration("dial-timeout", 0, "give up if a dial takes longer than this; in SOCKS5 mode the client gets an error reply, in transparent mode its connection is closed (0 = wait forever)")
+	)
+	flag.Parse()
+	if *myCall == "" {
+		fmt.Println("set mycall with -c")
+		os.Exit(1)
+	}
+
+	modem, err := vara.NewModem(*scheme, *myCall, vara.ModemConfig{})
+	if err != nil {
+		log.Fatalf("couldn't initialize VARA modem: %v", err)
+	}
+
+	if *transparent {
+		if *remote == "" {
+			fmt.Println("set the remote callsign with -remote in transparent mode")
+			os.Exit(1)
+		}
+		srv := &socksgw.TransparentServer{Modem: modem, Scheme: *scheme, Remote: *remote, DialTimeout: *dialTimeout}
+		log.Printf("Bridging %s to %s over %s...", *listen, *remote, *scheme)
+		log.Fatal(srv.ListenAndServe(*listen))
+	}
+
+	srv := &socksgw.Server{Modem: modem, Scheme: *scheme, Username: *user, Password: *pass, DialTimeout: *dialTimeout}
+	log.Printf("Serving SOCKS5 on %s, dialing over %s...", *listen, *scheme)
+	log.Fatal(srv.ListenAndServe(*listen))
+}
@@ -2,35 +2,126 @@ package vara
 
 import (
 	"net"
+	"sync"
+	"time"
 )
 
-// Wrapper for the data port connection we hand to clients. Implements net.Conn.
-type varaDataConn struct {
-	// the underlying TCP conn we're wrapping (type embedding)
-	net.TCPConn
+// dataConn holds what's common to the connections handed out for a VARA data session,
+// whether dialed (varaDataConn) or accepted (varaSession): the data port TCP conn plus
+// RF-aware deadline handling. It does not itself implement net.Conn.Close, since what
+// closing a session means differs between the two (see varaDataConn and varaSession).
+type dataConn struct {
+	// the underlying TCP conn we're wrapping. Held by pointer rather than embedded by
+	// value, since net.TCPConn carries internal mutexes that must not be copied.
+	conn *net.TCPConn
 	// the parent modem hosting this connection
-	modem Modem
+	modem *Modem
+
+	// watchdogMu guards watchdog, which is armed/disarmed from whichever goroutine
+	// calls the deadline setters.
+	watchdogMu sync.Mutex
+	watchdog   *time.Timer
 }
 
-// Close closes the connection.
-// Any blocked Read or Write operations will be unblocked and return errors.
+// Read reads data from the connection.
 //
-// Implements net.Conn.Close.
-func (v *varaDataConn) Close() error {
-	// If client wants to close the data stream, close down RF and TCP as well
-	return v.modem.Close()
+// Implements net.Conn.Read.
+func (v *dataConn) Read(b []byte) (int, error) {
+	return v.conn.Read(b)
+}
+
+// Write writes data to the connection.
+//
+// Implements net.Conn.Write.
+func (v *dataConn) Write(b []byte) (int, error) {
+	return v.conn.Write(b)
 }
 
 // LocalAddr returns the local network address.
 //
 // Implements net.Conn.LocalAddr.
-func (v *varaDataConn) LocalAddr() net.Addr {
+func (v *dataConn) LocalAddr() net.Addr {
 	return Addr{v.modem.myCall, v.modem.scheme}
 }
 
 // RemoteAddr returns the remote network address.
 //
 // Implements net.Conn.RemoteAddr.
-func (v *varaDataConn) RemoteAddr() net.Addr {
+func (v *dataConn) RemoteAddr() net.Addr {
 	return Addr{v.modem.toCall, v.modem.scheme}
 }
+
+// SetDeadline sets the read and write deadlines associated with the connection.
+//
+// Implements net.Conn.SetDeadline.
+func (v *dataConn) SetDeadline(t time.Time) error {
+	if err := v.conn.SetDeadline(t); err != nil {
+		return err
+	}
+	v.armWatchdog(t)
+	return nil
+}
+
+// SetReadDeadline sets the deadline for future Read calls.
+//
+// Implements net.Conn.SetReadDeadline.
+func (v *dataConn) SetReadDeadline(t time.Time) error {
+	if err := v.conn.SetReadDeadline(t); err != nil {
+		return err
+	}
+	v.armWatchdog(t)
+	return nil
+}
+
+// SetWriteDeadline sets the deadline for future Write calls.
+//
+// Implements net.Conn.SetWriteDeadline.
+func (v *dataConn) SetWriteDeadline(t time.Time) error {
+	if err := v.conn.SetWriteDeadline(t); err != nil {
+		return err
+	}
+	v.armWatchdog(t)
+	return nil
+}
+
+// armWatchdog schedules (or, given a zero Time, cancels) an RF-idle check at t. The
+// data TCP port is just a local handoff to VARA's own buffer, so a TCP-level deadline
+// alone can't tell a stalled RF session from one that's merely quiet locally. At t, if
+// VARA hasn't reported BUFFER progress on the command port since the deadline was set,
+// the session is aborted so the blocked Read/Write unblocks instead of waiting forever
+// on RF that has stalled.
+func (v *dataConn) armWatchdog(t time.Time) {
+	v.watchdogMu.Lock()
+	defer v.watchdogMu.Unlock()
+
+	if v.watchdog != nil {
+		v.watchdog.Stop()
+		v.watchdog = nil
+	}
+	if t.IsZero() {
+		return
+	}
+
+	baseline := v.modem.bufferActivityAt()
+	v.watchdog = time.AfterFunc(time.Until(t), func() {
+		if v.modem.bufferActivityAt().After(baseline) {
+			return // RF made progress since the deadline was set; nothing is stalled
+		}
+		_ = v.modem.writeCmd("ABORT")
+	})
+}
+
+// varaDataConn is the connection handed back by DialURL/DialURLContext. Implements
+// net.Conn.
+type varaDataConn struct {
+	dataConn
+}
+
+// Close closes the connection, disconnecting the RF session and the modem's TCP ports.
+// Any blocked Read or Write operations will be unblocked and return errors.
+//
+// Implements net.Conn.Close.
+func (v *varaDataConn) Close() error {
+	v.armWatchdog(time.Time{})
+	return v.modem.Close()
+}
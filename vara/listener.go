@@ -1,29 +1,186 @@
 package vara
 
 import (
+	"context"
 	"errors"
 	"net"
+	"sync"
+	"time"
 )
 
-// Implementation for the net.Listener interface.
-// (Close method is implemented in connection.go.)
+// Listener accepts successive inbound VARA RF sessions, in the style of net.TCPListener.
+// Unlike a single DialURL/Accept, the command and data TCP ports stay open across
+// sessions, so a station can serve more than one caller over its lifetime.
+//
+// While listening, a Listener's dispatch goroutine is the sole reader of
+// modem.connectChange, routing each CONNECTED/DISCONNECTED to whichever of an
+// in-flight AcceptContext or the current varaSession's Close is waiting for it. This
+// keeps a disconnect noticed while a session is in use from being mistaken for the
+// result of an unrelated later Accept, and vice versa.
+type Listener struct {
+	modem *Modem
 
-// Accept waits for and returns the next connection to the listener.
-func (m *Modem) Accept() (net.Conn, error) {
+	mu      sync.Mutex
+	waiter  chan connectedState // set while an AcceptContext call is waiting for CONNECTED
+	session chan connectedState // set while a varaSession is waiting for DISCONNECTED
 
-	// Block until connected
-	if <-m.connectChange != connected {
-		m.dataConn = nil
-		return nil, errors.New("connection failed")
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// Listen starts the command/data TCP ports and tells VARA to listen for inbound
+// connections, returning a Listener that yields one net.Conn per CONNECTED/DISCONNECTED
+// cycle.
+func (m *Modem) Listen() (*Listener, error) {
+	if err := m.start(); err != nil {
+		return nil, err
 	}
+	if err := m.writeCmd("LISTEN ON"); err != nil {
+		return nil, err
+	}
+	m.listening = true
+	l := &Listener{modem: m, closed: make(chan struct{})}
+	go l.dispatch()
+	return l, nil
+}
+
+// dispatch is the sole consumer of modem.connectChange while listening. It hands each
+// value off to whichever of l.waiter (an in-flight AcceptContext) or l.session (the
+// current varaSession's Close/endSession) is expecting it, so the two never race over
+// the same channel read.
+func (l *Listener) dispatch() {
+	for {
+		select {
+		case res := <-l.modem.connectChange:
+			l.mu.Lock()
+			switch {
+			case res == connected && l.waiter != nil:
+				l.waiter <- res
+				l.waiter = nil
+			case res == disconnected && l.session != nil:
+				l.session <- res
+				l.session = nil
+			case res == connected:
+				// CONNECTED with nobody in AcceptContext (e.g. it was cancelled
+				// just before this arrived); nothing to deliver it to.
+			default:
+				// DISCONNECTED with nobody in Close (e.g. the remote dropped
+				// spontaneously); nothing to deliver it to.
+			}
+			l.mu.Unlock()
+		case <-l.closed:
+			return
+		}
+	}
+}
 
-	// Hand the VARA data TCP port to the client code
-	return &varaDataConn{*m.dataConn, *m}, nil
+// Accept waits for and returns the next inbound RF session.
+func (l *Listener) Accept() (net.Conn, error) {
+	return l.AcceptContext(context.Background())
+}
+
+// AcceptContext is Accept, but returns ctx.Err() if ctx is cancelled first. Once Close
+// has been called, Accept/AcceptContext return net.ErrClosed. Only one AcceptContext
+// call may be outstanding at a time.
+func (l *Listener) AcceptContext(ctx context.Context) (net.Conn, error) {
+	select {
+	case <-l.closed:
+		return nil, net.ErrClosed
+	default:
+	}
+
+	l.mu.Lock()
+	if l.waiter != nil {
+		l.mu.Unlock()
+		return nil, errors.New("accept already in progress")
+	}
+	w := make(chan connectedState, 1)
+	l.waiter = w
+	l.mu.Unlock()
+
+	select {
+	case res := <-w:
+		if res != connected {
+			return nil, errors.New("connection failed")
+		}
+	case <-l.closed:
+		return nil, net.ErrClosed
+	case <-ctx.Done():
+		// Leave l.waiter set: dispatch still needs somewhere to deliver the
+		// CONNECTED this attempt may yet produce, so it doesn't leak into a
+		// later, unrelated Accept call.
+		return nil, ctx.Err()
+	}
+
+	return &varaSession{dataConn{conn: l.modem.dataConn, modem: l.modem}, l}, nil
 }
 
 // Addr returns the listener's network address.
-func (m *Modem) Addr() net.Addr {
-	return Addr{m.myCall, m.scheme}
+func (l *Listener) Addr() net.Addr {
+	return Addr{l.modem.myCall, l.modem.scheme}
+}
+
+// Close stops listening for inbound RF sessions and shuts down the command/data TCP
+// ports. It does not call Close on a varaSession already handed out by Accept, but
+// that session's data TCP port is closed along with the rest, so its Read and Write
+// fail from then on.
+func (l *Listener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.closed)
+		err = l.modem.writeCmd("LISTEN OFF")
+		l.modem.teardown()
+	})
+	return err
+}
+
+// endSession disconnects the RF session belonging to a varaSession by sending
+// DISCONNECT and waiting for dispatch to deliver the matching DISCONNECTED (falling
+// back to ABORT on ctx cancellation or ModemConfig.DisconnectTimeout). If the Listener
+// is closed while waiting, it returns nil without sending anything further.
+func (l *Listener) endSession(ctx context.Context) error {
+	if l.modem.lastState != connected {
+		return nil
+	}
+
+	l.mu.Lock()
+	done := make(chan connectedState, 1)
+	l.session = done
+	l.mu.Unlock()
+
+	if err := l.modem.writeCmd("DISCONNECT"); err != nil {
+		return err
+	}
+
+	select {
+	case res := <-done:
+		if res != disconnected {
+			l.modem.config.Logger.WithFields(l.modem.logFields()).Warnf("disconnect failed, aborting")
+			return l.modem.writeCmd("ABORT")
+		}
+		return nil
+	case <-l.closed:
+		return nil
+	case <-ctx.Done():
+		_ = l.modem.writeCmd("ABORT")
+		return ctx.Err()
+	case <-time.After(l.modem.config.DisconnectTimeout):
+		return l.modem.writeCmd("ABORT")
+	}
+}
+
+// varaSession is one inbound RF session accepted from a Listener. Implements net.Conn.
+type varaSession struct {
+	dataConn
+	listener *Listener
+}
+
+// Close disconnects the RF session, but leaves the modem listening for the next one.
+//
+// Implements net.Conn.Close.
+func (v *varaSession) Close() error {
+	v.armWatchdog(time.Time{})
+	return v.listener.endSession(context.Background())
 }
 
 type Addr struct {
@@ -33,13 +190,3 @@ type Addr struct {
 
 func (a Addr) Network() string { return a.scheme }
 func (a Addr) String() string  { return a.string }
-
-func (m *Modem) Listen() (net.Listener, error) {
-	if err := m.start(); err != nil {
-		return nil, err
-	}
-	if err := m.writeCmd("LISTEN ON"); err != nil {
-		return nil, err
-	}
-	return m, nil
-}
@@ -0,0 +1,74 @@
+package vara
+
+import (
+	"fmt"
+	"log"
+	"sort"
+)
+
+// Logger is the diagnostics sink used by a Modem. Implementations can route VARA
+// diagnostics through logrus, zap, slog, or a test capture sink by setting
+// ModemConfig.Logger; see defaultLogger for the logger used when it's left unset.
+//
+// WithFields attaches structured fields (e.g. remote, scheme, bw) to the logger
+// returned, so a backend that supports it can classify or index on them instead of
+// only seeing them interpolated into the message text.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+	WithFields(fields map[string]interface{}) Logger
+}
+
+// defaultLogger is used when ModemConfig.Logger is unset. It writes to the standard
+// "log" package with the historical "[VARA]" prefix, rendering any fields attached via
+// WithFields as trailing "key=value" pairs. Debugf output is only emitted when the
+// VARA_DEBUG environment variable is set, matching the old debugPrint behavior.
+type defaultLogger struct {
+	fields map[string]interface{}
+}
+
+func (l defaultLogger) WithFields(fields map[string]interface{}) Logger {
+	merged := make(map[string]interface{}, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return defaultLogger{fields: merged}
+}
+
+func (l defaultLogger) print(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+
+	keys := make([]string, 0, len(l.fields))
+	for k := range l.fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		msg += fmt.Sprintf(" %s=%v", k, l.fields[k])
+	}
+
+	log.Printf("[VARA] %s", msg)
+}
+
+func (l defaultLogger) Debugf(format string, args ...interface{}) {
+	if debug {
+		l.print(format, args...)
+	}
+}
+
+func (l defaultLogger) Infof(format string, args ...interface{}) {
+	l.print(format, args...)
+}
+
+func (l defaultLogger) Warnf(format string, args ...interface{}) {
+	l.print(format, args...)
+}
+
+func (l defaultLogger) Errorf(format string, args ...interface{}) {
+	l.print(format, args...)
+}
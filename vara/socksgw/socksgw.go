@@ -0,0 +1,313 @@
+// Package socksgw implements a SOCKS5 gateway (RFC 1928) that bridges TCP clients into
+// VARA RF sessions, so arbitrary TCP applications can be pointed at a callsign without
+// knowing anything about VARA.
+package socksgw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/la5nta/wl2k-go/transport"
+	"github.com/n8jja/Pat-Vara/vara"
+)
+
+// SOCKS5 protocol constants, see RFC 1928 and RFC 1929.
+const (
+	socksVersion5 = 0x05
+	authVersion1  = 0x01
+
+	methodNoAuth       = 0x00
+	methodUserPass     = 0x02
+	methodNoAcceptable = 0xff
+
+	cmdConnect = 0x01
+
+	atypIPv4   = 0x01
+	atypDomain = 0x03
+	atypIPv6   = 0x04
+
+	replySucceeded           = 0x00
+	replyHostUnreachable     = 0x04
+	replyTTLExpired          = 0x06
+	replyCommandNotSupported = 0x07
+	replyAddrNotSupported    = 0x08
+)
+
+// Server is a SOCKS5 gateway that dials VARA RF sessions on behalf of CONNECT requests.
+// The destination host of a request is the remote callsign, optionally followed by a
+// query string of dial parameters, e.g. "W1AW-10" or "W1AW-10?bw=500&p2p=true".
+//
+// Only the CONNECT command is supported; BIND and UDP ASSOCIATE are rejected.
+type Server struct {
+	// Modem is used to dial out. It must not be driven by anything else while the
+	// Server is running.
+	Modem *vara.Modem
+	// Scheme is the VARA scheme to dial with (e.g. "varahf"). It must match the
+	// scheme Modem was created with.
+	Scheme string
+	// Username and Password, if both non-empty, require SOCKS5 USERNAME/PASSWORD
+	// auth (RFC 1929) from connecting clients. Otherwise NO-AUTH is offered.
+	Username, Password string
+	// DialTimeout bounds how long a CONNECT request waits for VARA to establish the
+	// RF session before replying with replyTTLExpired. Zero means wait forever.
+	DialTimeout time.Duration
+
+	// mu serializes RF dials: a Modem has one RF channel, so only one session can be
+	// dialing or connected at a time. A second client queues until the first is done.
+	mu sync.Mutex
+}
+
+// ListenAndServe listens on addr and serves SOCKS5 connections until an error occurs.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("socksgw: listen: %w", err)
+	}
+	defer l.Close()
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l, handling each as a SOCKS5 client.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handleConn(conn)
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	if err := s.negotiate(conn); err != nil {
+		log.Printf("socksgw: %v: %v", conn.RemoteAddr(), err)
+		return
+	}
+	host, err := readRequest(conn)
+	if err != nil {
+		log.Printf("socksgw: %v: %v", conn.RemoteAddr(), err)
+		return
+	}
+	target, err := parseTarget(s.Scheme, host)
+	if err != nil {
+		writeReply(conn, replyAddrNotSupported)
+		log.Printf("socksgw: %v: %v", conn.RemoteAddr(), err)
+		return
+	}
+
+	ctx := context.Background()
+	if s.DialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.DialTimeout)
+		defer cancel()
+	}
+
+	s.mu.Lock()
+	rf, err := s.Modem.DialURLContext(ctx, target)
+	if err != nil {
+		s.mu.Unlock()
+		writeReply(conn, mapDialErr(err))
+		log.Printf("socksgw: dial %s: %v", target.Target, err)
+		return
+	}
+	defer func() {
+		rf.Close()
+		s.mu.Unlock()
+	}()
+
+	if err := writeReply(conn, replySucceeded); err != nil {
+		return
+	}
+	proxy(conn, rf)
+}
+
+// negotiate performs the SOCKS5 method negotiation and, if USERNAME/PASSWORD was
+// selected, the RFC 1929 sub-negotiation.
+func (s *Server) negotiate(conn net.Conn) error {
+	hdr := make([]byte, 2)
+	if _, err := io.ReadFull(conn, hdr); err != nil {
+		return fmt.Errorf("read greeting: %w", err)
+	}
+	if hdr[0] != socksVersion5 {
+		return fmt.Errorf("unsupported SOCKS version %d", hdr[0])
+	}
+	methods := make([]byte, hdr[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return fmt.Errorf("read methods: %w", err)
+	}
+
+	wantAuth := s.Username != "" && s.Password != ""
+	chosen := byte(methodNoAcceptable)
+	for _, m := range methods {
+		if wantAuth && m == methodUserPass {
+			chosen = methodUserPass
+			break
+		}
+		if !wantAuth && m == methodNoAuth {
+			chosen = methodNoAuth
+			break
+		}
+	}
+	if _, err := conn.Write([]byte{socksVersion5, chosen}); err != nil {
+		return fmt.Errorf("write method choice: %w", err)
+	}
+	if chosen == methodNoAcceptable {
+		return errors.New("no acceptable auth method offered")
+	}
+	if chosen == methodUserPass {
+		return s.authenticate(conn)
+	}
+	return nil
+}
+
+func (s *Server) authenticate(conn net.Conn) error {
+	hdr := make([]byte, 2)
+	if _, err := io.ReadFull(conn, hdr); err != nil {
+		return fmt.Errorf("read auth header: %w", err)
+	}
+	if hdr[0] != authVersion1 {
+		return fmt.Errorf("unsupported auth version %d", hdr[0])
+	}
+	uname := make([]byte, hdr[1])
+	if _, err := io.ReadFull(conn, uname); err != nil {
+		return fmt.Errorf("read username: %w", err)
+	}
+	plen := make([]byte, 1)
+	if _, err := io.ReadFull(conn, plen); err != nil {
+		return fmt.Errorf("read password length: %w", err)
+	}
+	passwd := make([]byte, plen[0])
+	if _, err := io.ReadFull(conn, passwd); err != nil {
+		return fmt.Errorf("read password: %w", err)
+	}
+
+	ok := string(uname) == s.Username && string(passwd) == s.Password
+	status := byte(0x00)
+	if !ok {
+		status = 0x01
+	}
+	if _, err := conn.Write([]byte{authVersion1, status}); err != nil {
+		return fmt.Errorf("write auth status: %w", err)
+	}
+	if !ok {
+		return errors.New("auth failed")
+	}
+	return nil
+}
+
+// readRequest reads a SOCKS5 request and returns its destination host. BIND and UDP
+// ASSOCIATE are rejected with a SOCKS error reply; only CONNECT is handled.
+func readRequest(conn net.Conn) (string, error) {
+	hdr := make([]byte, 4)
+	if _, err := io.ReadFull(conn, hdr); err != nil {
+		return "", fmt.Errorf("read request header: %w", err)
+	}
+	if hdr[0] != socksVersion5 {
+		return "", fmt.Errorf("unsupported SOCKS version %d", hdr[0])
+	}
+	if hdr[1] != cmdConnect {
+		writeReply(conn, replyCommandNotSupported)
+		return "", fmt.Errorf("unsupported command %d (only CONNECT is supported)", hdr[1])
+	}
+
+	var host string
+	switch atyp := hdr[3]; atyp {
+	case atypDomain:
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return "", err
+		}
+		b := make([]byte, l[0])
+		if _, err := io.ReadFull(conn, b); err != nil {
+			return "", err
+		}
+		host = string(b)
+	case atypIPv4:
+		b := make([]byte, 4)
+		if _, err := io.ReadFull(conn, b); err != nil {
+			return "", err
+		}
+		host = net.IP(b).String()
+	case atypIPv6:
+		b := make([]byte, 16)
+		if _, err := io.ReadFull(conn, b); err != nil {
+			return "", err
+		}
+		host = net.IP(b).String()
+	default:
+		writeReply(conn, replyAddrNotSupported)
+		return "", fmt.Errorf("unsupported address type %d", atyp)
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return "", err
+	}
+	return host, nil
+}
+
+func writeReply(conn net.Conn, rep byte) error {
+	// BND.ADDR/BND.PORT have no meaning for a VARA RF destination; report 0.0.0.0:0.
+	_, err := conn.Write([]byte{socksVersion5, rep, 0x00, atypIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
+
+// validCallsign matches the VARA callsign charset (letters, digits, optional SSID/path
+// separated by "-" or "/"). The SOCKS5 destination host is attacker-controlled (any
+// connecting TCP client picks it) and ends up unsanitized in the CONNECT command
+// DialURLContext writes to VARA's raw, CR-delimited command socket, so anything outside
+// this charset - most importantly CR/LF, which could inject additional VARA commands -
+// must be rejected before it gets anywhere near that.
+var validCallsign = regexp.MustCompile(`^[A-Za-z0-9/-]+$`)
+
+// parseTarget turns a SOCKS5 destination host, e.g. "W1AW-10" or "W1AW-10?bw=500", into
+// a dial URL for the given scheme.
+func parseTarget(scheme, host string) (*transport.URL, error) {
+	call, rawQuery, _ := strings.Cut(host, "?")
+	if call == "" {
+		return nil, errors.New("empty destination callsign")
+	}
+	if !validCallsign.MatchString(call) {
+		return nil, fmt.Errorf("invalid destination callsign %q", call)
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dial params: %w", err)
+	}
+	return &transport.URL{Scheme: scheme, Target: call, Params: map[string][]string(values)}, nil
+}
+
+// mapDialErr maps a DialURLContext error to the closest-matching SOCKS5 reply code.
+func mapDialErr(err error) byte {
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() {
+		return replyTTLExpired
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return replyTTLExpired
+	}
+	return replyHostUnreachable
+}
+
+// proxy copies bytes bidirectionally between a and b until either side is done.
+func proxy(a, b net.Conn) {
+	done := make(chan struct{}, 2)
+	cp := func(dst, src net.Conn) {
+		io.Copy(dst, src)
+		done <- struct{}{}
+	}
+	go cp(a, b)
+	go cp(b, a)
+	<-done
+}
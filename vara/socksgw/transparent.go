@@ -0,0 +1,87 @@
+package socksgw
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/n8jja/Pat-Vara/vara"
+)
+
+// TransparentServer hardwires a single local TCP port to a single remote callsign: every
+// accepted connection is bridged directly into a VARA RF session with no SOCKS framing,
+// for clients that can't be configured to use a SOCKS5 proxy.
+type TransparentServer struct {
+	// Modem is used to dial out. It must not be driven by anything else while the
+	// TransparentServer is running.
+	Modem *vara.Modem
+	// Scheme is the VARA scheme to dial with (e.g. "varahf"). It must match the
+	// scheme Modem was created with.
+	Scheme string
+	// Remote is the destination callsign to dial, optionally followed by a query
+	// string of dial params, e.g. "W1AW-10" or "W1AW-10?bw=500&p2p=true".
+	Remote string
+	// DialTimeout bounds how long a connection waits for VARA to establish the RF
+	// session before giving up. Zero means wait forever.
+	DialTimeout time.Duration
+
+	// mu serializes RF dials: a Modem has one RF channel, so only one session can be
+	// dialing or connected at a time.
+	mu sync.Mutex
+}
+
+// ListenAndServe listens on addr and bridges every accepted connection to Remote until
+// an error occurs.
+func (s *TransparentServer) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("socksgw: listen: %w", err)
+	}
+	defer l.Close()
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l, bridging each one to Remote.
+func (s *TransparentServer) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handleConn(conn)
+	}
+}
+
+func (s *TransparentServer) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	target, err := parseTarget(s.Scheme, s.Remote)
+	if err != nil {
+		log.Printf("socksgw: transparent: %v", err)
+		return
+	}
+
+	ctx := context.Background()
+	if s.DialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.DialTimeout)
+		defer cancel()
+	}
+
+	s.mu.Lock()
+	rf, err := s.Modem.DialURLContext(ctx, target)
+	if err != nil {
+		s.mu.Unlock()
+		log.Printf("socksgw: transparent: dial %s: %v", s.Remote, err)
+		return
+	}
+	defer func() {
+		rf.Close()
+		s.mu.Unlock()
+	}()
+
+	proxy(conn, rf)
+}
@@ -1,16 +1,26 @@
 package vara
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net"
+	"time"
 
 	"github.com/la5nta/wl2k-go/transport"
 )
 
 // Implementations for various wl2k-go/transport interfaces.
 
+// DialURL is DialURLContext with context.Background().
 func (m *Modem) DialURL(url *transport.URL) (net.Conn, error) {
+	return m.DialURLContext(context.Background(), url)
+}
+
+// DialURLContext dials out to the callsign and params in url, as DialURL does, but
+// returns ctx.Err() if ctx is cancelled before VARA reports CONNECTED. On cancellation,
+// ABORT is sent to VARA and toCall is reset so the Modem is ready for another dial.
+func (m *Modem) DialURLContext(ctx context.Context, url *transport.URL) (net.Conn, error) {
 	if url.Scheme != m.scheme {
 		return nil, transport.ErrUnsupportedScheme
 	}
@@ -45,14 +55,49 @@ func (m *Modem) DialURL(url *transport.URL) (net.Conn, error) {
 		return nil, err
 	}
 
-	// Block until connected
-	if <-m.connectChange != connected {
-		m.dataConn = nil
-		return nil, errors.New("connection failed")
+	// Block until connected, or ctx is cancelled. connWaitMu keeps this (and a
+	// background drain left behind by cancellation, below) as the only goroutine
+	// ever receiving from connectChange at a time, so a later DialURLContext/Close
+	// call on the same Modem can't race an abandoned one for the same value; it
+	// blocks on the lock until the abandoned wait is fully drained instead.
+	m.connWaitMu.Lock()
+	select {
+	case res := <-m.connectChange:
+		m.connWaitMu.Unlock()
+		if res != connected {
+			m.dataConn = nil
+			return nil, errors.New("connection failed")
+		}
+	case <-ctx.Done():
+		_ = m.writeCmd("ABORT")
+		m.toCall = ""
+		go func() {
+			// Drain until we see the DISCONNECTED this ABORT provokes; CONNECTED for
+			// the attempt we just gave up on can still land first and must not be
+			// mistaken for it. Holds connWaitMu the whole time, so it can't be
+			// handed to a later caller until this is done. Bounded by
+			// DisconnectTimeout too: if Close's teardown has already closed the TCP
+			// ports by the time this runs, nothing will ever arrive on
+			// connectChange again, and this must not hold connWaitMu forever.
+			defer m.connWaitMu.Unlock()
+			timeout := time.After(m.config.DisconnectTimeout)
+			for {
+				select {
+				case res := <-m.connectChange:
+					if res == disconnected {
+						return
+					}
+				case <-timeout:
+					m.config.Logger.WithFields(m.logFields()).Warnf("never saw the abort of a cancelled dial get acked; giving up waiting")
+					return
+				}
+			}
+		}()
+		return nil, ctx.Err()
 	}
 
 	// Hand the VARA data TCP port to the client code
-	return &varaDataConn{*m.dataConn, *m}, nil
+	return &varaDataConn{dataConn{conn: m.dataConn, modem: m}}, nil
 }
 
 func (m *Modem) setBandwidth(url *transport.URL) error {
@@ -63,6 +108,7 @@ func (m *Modem) setBandwidth(url *transport.URL) error {
 	if !contains(bandwidths, bw) {
 		return errors.New(fmt.Sprintf("bandwidth %s not supported", bw))
 	}
+	m.dialBW = bw
 	return m.writeCmd(fmt.Sprintf("BW%s", bw))
 }
 
@@ -1,13 +1,14 @@
 package vara
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/imdario/mergo"
@@ -23,25 +24,55 @@ type ModemConfig struct {
 	// DataPort is the TCP port on which to exchange over-the-air payloads with VARA;
 	// defaults to 8301
 	DataPort int
+	// DisconnectTimeout is how long Close waits for VARA to ack a DISCONNECT before
+	// falling back to ABORT; defaults to 10s.
+	DisconnectTimeout time.Duration
+	// Logger receives diagnostics from the modem; defaults to a logger writing to the
+	// standard "log" package, gated by the VARA_DEBUG environment variable for Debugf.
+	Logger Logger
 }
 
 var defaultConfig = ModemConfig{
-	Host:     "localhost",
-	CmdPort:  8300,
-	DataPort: 8301,
+	Host:              "localhost",
+	CmdPort:           8300,
+	DataPort:          8301,
+	DisconnectTimeout: 10 * time.Second,
+	Logger:            defaultLogger{},
 }
 
 type Modem struct {
-	scheme        string
-	myCall        string
-	config        ModemConfig
-	cmdConn       *net.TCPConn
-	dataConn      *net.TCPConn
-	toCall        string
+	scheme   string
+	myCall   string
+	config   ModemConfig
+	cmdConn  *net.TCPConn
+	dataConn *net.TCPConn
+	toCall   string
+	// dialBW is the bandwidth param ("500", "2300", "2750") from the most recent
+	// DialURLContext/setBandwidth call, kept only for inclusion in logFields; empty if
+	// none was given.
+	dialBW        string
 	busy          bool
 	connectChange chan connectedState
 	lastState     connectedState
 	rig           transport.PTTController
+	// listening is true between Listen() and Listener.Close(). While true, a
+	// DISCONNECTED session leaves the TCP ports open so the Listener can keep
+	// accepting; see handleDisconnect.
+	listening bool
+
+	// connWaitMu serializes every wait on connectChange from the Dial/Close side
+	// (DialURLContext, endSession), including a background drain left behind by a
+	// cancelled one: it's held from the moment a caller starts waiting until it (or
+	// its drain goroutine) has fully consumed the matching value, so an abandoned
+	// wait can never be sitting on connectChange to steal the value meant for a
+	// later, unrelated caller. Listener has its own dispatcher for the Accept side,
+	// since a Modem is never driven by both at once.
+	connWaitMu sync.Mutex
+
+	// bufferMu guards lastBuffer, which varaDataConn's deadline watchdog reads from
+	// a different goroutine than the one handling incoming VARA commands.
+	bufferMu   sync.Mutex
+	lastBuffer time.Time
 }
 
 type connectedState int
@@ -129,42 +160,108 @@ func (m *Modem) start() error {
 	return nil
 }
 
-// Close closes the RF and then the TCP connections to the VARA modem. Blocks until finished.
+// Close closes the RF and then the TCP connections to the VARA modem. Blocks until
+// finished: it waits up to ModemConfig.DisconnectTimeout for VARA to ack the
+// DISCONNECT, then sends ABORT and waits, with no further time limit, for that ack.
 func (m *Modem) Close() error {
-	// Block until VARA modem acks disconnect
-	if m.lastState == connected {
-		// Send DISCONNECT command
-		if m.cmdConn != nil {
-			if err := m.writeCmd("DISCONNECT"); err != nil {
-				return err
-			}
+	return m.CloseContext(context.Background())
+}
+
+// CloseContext is Close, but the wait for VARA to ack the disconnect can also be cut
+// short by cancelling ctx, in which case ABORT is sent and ctx.Err() is returned.
+func (m *Modem) CloseContext(ctx context.Context) error {
+	// Tear down the TCP ports no matter how endSession below comes out; unlike
+	// endSession, a full Close always does this, so a cancelled or failed endSession
+	// doesn't leak the TCP ports and cmdListen's goroutine.
+	defer m.teardown()
+
+	err := m.endSession(ctx)
+
+	// Make sure to stop TX (should have already happened, but this is a backup)
+	m.sendPTT(false)
+
+	return err
+}
+
+// endSession disconnects the current RF session, if any, by sending DISCONNECT and
+// waiting for VARA to ack it (falling back to ABORT on ctx cancellation or
+// ModemConfig.DisconnectTimeout). It leaves the command/data TCP ports untouched;
+// handleDisconnect takes care of those, guarded by m.listening.
+func (m *Modem) endSession(ctx context.Context) error {
+	if m.lastState != connected {
+		return nil
+	}
+	if m.cmdConn != nil {
+		if err := m.writeCmd("DISCONNECT"); err != nil {
+			return err
 		}
+	}
 
-		select {
-		case res := <-m.connectChange:
-			if res != disconnected {
-				log.Println("Disconnect failed, aborting!")
-				if err := m.writeCmd("ABORT"); err != nil {
-					return err
-				}
-			}
-		case <-time.After(time.Second * 10):
+	m.connWaitMu.Lock()
+	select {
+	case res := <-m.connectChange:
+		if res != disconnected {
+			m.config.Logger.WithFields(m.logFields()).Warnf("disconnect failed, aborting")
 			if err := m.writeCmd("ABORT"); err != nil {
+				m.connWaitMu.Unlock()
 				return err
 			}
+			<-m.connectChange
+		}
+		m.connWaitMu.Unlock()
+		return nil
+	case <-ctx.Done():
+		_ = m.writeCmd("ABORT")
+		// Drain in the background, still holding connWaitMu until the abandoned
+		// wait's DISCONNECTED actually shows up, so it can't race a later
+		// endSession/DialURLContext call for the same value. Bounded by
+		// DisconnectTimeout too: if CloseContext's teardown has already closed the
+		// TCP ports by the time this runs, nothing will ever arrive on
+		// connectChange again, and this must not hold connWaitMu forever.
+		go func() {
+			defer m.connWaitMu.Unlock()
+			select {
+			case <-m.connectChange:
+			case <-time.After(m.config.DisconnectTimeout):
+				m.config.Logger.WithFields(m.logFields()).Warnf("never saw the abort of a cancelled close get acked; giving up waiting")
+			}
+		}()
+		return ctx.Err()
+	case <-time.After(m.config.DisconnectTimeout):
+		defer m.connWaitMu.Unlock()
+		if err := m.writeCmd("ABORT"); err != nil {
+			return err
 		}
+		<-m.connectChange
+		return nil
 	}
+}
 
-	// Make sure to stop TX (should have already happened, but this is a backup)
-	m.sendPTT(false)
+// teardown closes the command/data TCP ports and resets session state, regardless of
+// m.listening. Used by CloseContext and Listener.Close, which (unlike a session ending)
+// always mean to shut the whole modem down.
+func (m *Modem) teardown() {
+	m.dataConn = disconnectTCP(m.config.Logger, "data", m.dataConn)
+	m.cmdConn = disconnectTCP(m.config.Logger, "command", m.cmdConn)
+	m.lastState = disconnected
+	m.toCall = ""
+	m.dialBW = ""
+	m.busy = false
+	m.listening = false
+}
 
-	// Clear up internal state
-	m.handleDisconnect()
-	return nil
+// logFields returns the structured fields (remote, scheme, bw) that should accompany a
+// log line about the current session, for use with Logger.WithFields.
+func (m *Modem) logFields() map[string]interface{} {
+	return map[string]interface{}{
+		"remote": m.toCall,
+		"scheme": m.scheme,
+		"bw":     m.dialBW,
+	}
 }
 
 func (m *Modem) connectTCP(name string, port int) (*net.TCPConn, error) {
-	debugPrint(fmt.Sprintf("Connecting %s TCP port", name))
+	m.config.Logger.WithFields(m.logFields()).Debugf("connecting %s TCP port", name)
 	cmdAddr, err := net.ResolveTCPAddr("tcp", fmt.Sprintf("%s:%d", m.config.Host, port))
 	if err != nil {
 		return nil, fmt.Errorf("couldn't resolve VARA %s address: %w", name, err)
@@ -176,18 +273,18 @@ func (m *Modem) connectTCP(name string, port int) (*net.TCPConn, error) {
 	return conn, nil
 }
 
-func disconnectTCP(name string, port *net.TCPConn) *net.TCPConn {
+func disconnectTCP(logger Logger, name string, port *net.TCPConn) *net.TCPConn {
 	if port == nil {
 		return nil
 	}
 	_ = port.Close()
-	debugPrint(fmt.Sprintf("disconnected %s TCP port", name))
+	logger.Debugf("disconnected %s TCP port", name)
 	return nil
 }
 
 // wrapper around m.cmdConn.Write
 func (m *Modem) writeCmd(cmd string) error {
-	debugPrint(fmt.Sprintf("writing cmd: %v", cmd))
+	m.config.Logger.WithFields(m.logFields()).Debugf("writing cmd: %v", cmd)
 	_, err := m.cmdConn.Write([]byte(cmd + "\r"))
 	return err
 }
@@ -210,7 +307,7 @@ func (m *Modem) cmdListen() {
 				// Connection closed
 				return
 			}
-			debugPrint(fmt.Sprintf("cmdListen err: %v", err))
+			m.config.Logger.WithFields(m.logFields()).Debugf("cmdListen err: %v", err)
 			continue
 		}
 		cmds := strings.Split(string(buf[:l]), "\r")
@@ -228,17 +325,21 @@ func (m *Modem) cmdListen() {
 // handleCmd handles one command coming from the VARA modem. It returns true if listening should
 // continue or false if listening should stop.
 func (m *Modem) handleCmd(c string) bool {
-	debugPrint(fmt.Sprintf("got cmd: %v", c))
+	m.config.Logger.WithFields(m.logFields()).Debugf("got cmd: %v", c)
 	switch c {
 	case "PTT ON":
 		// VARA wants to start TX; send that to the PTTController
+		m.config.Logger.WithFields(m.logFields()).Debugf("PTT ON")
 		m.sendPTT(true)
 	case "PTT OFF":
 		// VARA wants to stop TX; send that to the PTTController
+		m.config.Logger.WithFields(m.logFields()).Debugf("PTT OFF")
 		m.sendPTT(false)
 	case "BUSY ON":
+		m.config.Logger.WithFields(m.logFields()).Debugf("BUSY ON")
 		m.busy = true
 	case "BUSY OFF":
+		m.config.Logger.WithFields(m.logFields()).Debugf("BUSY OFF")
 		m.busy = false
 	case "OK":
 		// nothing to do
@@ -255,17 +356,18 @@ func (m *Modem) handleCmd(c string) bool {
 			break
 		}
 		if strings.HasPrefix(c, "BUFFER") {
-			// nothing to do
+			// RF is making progress; let the data conn's deadline watchdog know
+			m.recordBufferActivity()
 			break
 		}
 		if strings.HasPrefix(c, "REGISTERED") {
 			parts := strings.Split(c, " ")
 			if len(parts) > 1 {
-				log.Printf("VARA full speed available, registered to %s", parts[1])
+				m.config.Logger.WithFields(m.logFields()).Infof("VARA full speed available, registered to %s", parts[1])
 			}
 			break
 		}
-		log.Printf("got a vara command I wasn't expecting: %v", c)
+		m.config.Logger.WithFields(m.logFields()).Warnf("got a vara command I wasn't expecting: %v", c)
 	}
 	return true
 }
@@ -278,20 +380,39 @@ func (m *Modem) sendPTT(on bool) {
 
 func (m *Modem) handleConnect() {
 	m.lastState = connected
+	m.recordBufferActivity()
+	m.config.Logger.WithFields(m.logFields()).Infof("connected")
 	m.connectChange <- connected
 }
 
+// recordBufferActivity notes that VARA just reported buffer progress on the command
+// port, used as the "is the RF link still moving" signal for varaDataConn's deadlines.
+func (m *Modem) recordBufferActivity() {
+	m.bufferMu.Lock()
+	m.lastBuffer = time.Now()
+	m.bufferMu.Unlock()
+}
+
+func (m *Modem) bufferActivityAt() time.Time {
+	m.bufferMu.Lock()
+	defer m.bufferMu.Unlock()
+	return m.lastBuffer
+}
+
 func (m *Modem) handleDisconnect() {
+	m.config.Logger.WithFields(m.logFields()).Infof("disconnected")
 	m.lastState = disconnected
 	m.connectChange <- disconnected
-
-	// Close data port TCP connection
-	m.dataConn = disconnectTCP("data", m.dataConn)
-	// Close command port TCP connection
-	m.cmdConn = disconnectTCP("command", m.cmdConn)
-
 	m.toCall = ""
+	m.dialBW = ""
 	m.busy = false
+
+	// A Listener keeps the TCP ports open across sessions; only tear them down here
+	// for a one-shot (dialed) session. Listener.Close does its own teardown.
+	if !m.listening {
+		m.dataConn = disconnectTCP(m.config.Logger, "data", m.dataConn)
+		m.cmdConn = disconnectTCP(m.config.Logger, "command", m.cmdConn)
+	}
 }
 
 func (m *Modem) Ping() bool {
@@ -303,10 +424,3 @@ func (m *Modem) Version() (string, error) {
 	// TODO
 	return "v1", nil
 }
-
-// If env var VARA_DEBUG exists, log more stuff
-func debugPrint(msg string) {
-	if debug {
-		log.Printf("[VARA] %s", msg)
-	}
-}